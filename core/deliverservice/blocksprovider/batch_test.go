@@ -0,0 +1,83 @@
+/*
+Copyright IBM Corp. 2017 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package blocksprovider
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+func TestAdaptiveFanout(t *testing.T) {
+	tests := []struct {
+		name          string
+		numberOfPeers int
+		maxFanout     int
+		k             int
+		want          int
+	}{
+		{"no peers", 0, 0, 0, 0},
+		{"single peer", 1, 0, 0, 1},
+		{"uncapped grows with log2 of peers", 16, 0, 0, 4},
+		{"fanout constant k shifts the curve up", 16, 0, 2, 6},
+		{"maxFanout caps the result", 1024, 5, 0, 5},
+		{"fanout never exceeds peer count", 2, 10, 5, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := adaptiveFanout(tt.numberOfPeers, tt.maxFanout, tt.k)
+			if got != tt.want {
+				t.Fatalf("adaptiveFanout(%d, %d, %d) = %d, want %d", tt.numberOfPeers, tt.maxFanout, tt.k, got, tt.want)
+			}
+		})
+	}
+}
+
+// reconnectCountingClient is a streamClient that also implements
+// reconnectNotifier, so checkReconnect can be exercised without a real
+// reconnectingClient.
+type reconnectCountingClient struct {
+	erroringStreamClient
+	count uint64
+}
+
+func (c *reconnectCountingClient) ReconnectCount() uint64 { return atomic.LoadUint64(&c.count) }
+
+func TestCheckReconnectBypassesBatchingOnFailover(t *testing.T) {
+	client := &reconnectCountingClient{}
+	b := &blocksProviderImpl{client: client, metrics: NewNoopMetrics(), gapSignal: make(chan struct{}, 1)}
+
+	// No reconnect has happened yet: checkReconnect must not touch the flag.
+	atomic.StoreInt32(&b.firstAfterReconnect, 0)
+	b.checkReconnect()
+	if atomic.LoadInt32(&b.firstAfterReconnect) != 0 {
+		t.Fatal("checkReconnect set firstAfterReconnect without an observed reconnect")
+	}
+
+	// Simulate a transparent failover inside the client.
+	atomic.StoreUint64(&client.count, 1)
+	b.checkReconnect()
+	if atomic.LoadInt32(&b.firstAfterReconnect) != 1 {
+		t.Fatal("expected checkReconnect to bypass batching for the block right after a failover")
+	}
+
+	// Once observed, the same reconnect count must not re-trigger the flag.
+	atomic.StoreInt32(&b.firstAfterReconnect, 0)
+	b.checkReconnect()
+	if atomic.LoadInt32(&b.firstAfterReconnect) != 0 {
+		t.Fatal("checkReconnect re-triggered on an already-observed reconnect count")
+	}
+}
@@ -17,11 +17,22 @@ limitations under the License.
 package blocksprovider
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"sort"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 	gossipcommon "github.com/hyperledger/fabric/gossip/common"
 	"github.com/hyperledger/fabric/gossip/discovery"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/hyperledger/fabric/common/flogging"
 	"github.com/hyperledger/fabric/gossip/api"
@@ -31,6 +42,10 @@ import (
 	"github.com/op/go-logging"
 )
 
+// tracer emits spans covering block receipt, verification and gossip, so a
+// single block's path from orderer to committer can be followed end to end.
+var tracer = otel.Tracer("github.com/hyperledger/fabric/core/deliverservice/blocksprovider")
+
 // LedgerInfo an adapter to provide the interface to query
 // the ledger committer for current ledger height
 type LedgerInfo interface {
@@ -38,6 +53,20 @@ type LedgerInfo interface {
 	LedgerHeight() (uint64, error)
 }
 
+// LeaderElection tracks whether this peer is the leader responsible for
+// pulling blocks from the ordering service on behalf of its organization.
+// Only the leader actually streams from the orderer; the rest of the peers
+// in the org receive blocks exclusively through gossip.
+type LeaderElection interface {
+	// IsLeader returns whether this peer is currently the leader.
+	IsLeader() bool
+
+	// SubscribeLeadershipChanges registers changes to be notified of
+	// leadership transitions: true when this peer becomes leader, false
+	// when it loses leadership.
+	SubscribeLeadershipChanges(changes chan<- bool)
+}
+
 // GossipServiceAdapter serves to provide basic functionality
 // required from gossip service by delivery service
 type GossipServiceAdapter interface {
@@ -47,15 +76,42 @@ type GossipServiceAdapter interface {
 	// AddPayload adds payload to the local state sync buffer
 	AddPayload(chainID string, payload *gossip_proto.Payload) error
 
-	// Gossip the message across the peers
-	Gossip(msg *gossip_proto.GossipMessage)
+	// Gossip disseminates the envelope across the peers
+	Gossip(env *gossip_proto.Envelope)
+
+	// GossipToPeers disseminates the envelope only to the given subset of
+	// peers, used by the adaptive fan-out batching policy to bound fan-out
+	// on large channels.
+	GossipToPeers(env *gossip_proto.Envelope, peers []discovery.NetworkMember)
+
+	// SignMessage signs msg with this peer's identity, binding the
+	// signature to blockHash so that receivers can cheaply authenticate
+	// the envelope before running the costlier VerifyBlock.
+	SignMessage(msg *gossip_proto.GossipMessage, blockHash []byte) (*gossip_proto.Envelope, error)
+}
+
+// CapabilityProvider reports whether optional channel capabilities that
+// affect block delivery are enabled on the channel this provider serves.
+type CapabilityProvider interface {
+	// SignedGossipBlocks returns whether peers on the channel are expected
+	// to sign gossiped block dissemination messages.
+	SignedGossipBlocks() bool
 }
 
 // BlocksProvider used to read blocks from the ordering service
 // for specified chain it subscribed to
 type BlocksProvider interface {
-	// DeliverBlocks starts delivering and disseminating blocks
-	DeliverBlocks()
+	// DeliverBlocks starts delivering and disseminating blocks. ctx is used
+	// as the root of the per-block tracing spans emitted while the
+	// provider runs; it is not expected to be canceled in normal
+	// operation, only on shutdown alongside Stop.
+	DeliverBlocks(ctx context.Context)
+
+	// RequestBlocks asks the provider to pull blocks in the range [from, to]
+	// (inclusive) from the ordering service, independently of the live
+	// delivery stream. It is used by the state sync layer to backfill a
+	// hole discovered during gossip state reconciliation.
+	RequestBlocks(from, to uint64)
 
 	// Stop shutdowns blocks provider and stops delivering new blocks
 	Stop()
@@ -81,17 +137,143 @@ type streamClient interface {
 	Close()
 }
 
+// reconnectNotifier is optionally implemented by a streamClient that may
+// transparently reconnect across Recv calls (see reconnectingClient), so
+// blocksProviderImpl can detect that a fresh connection was established and
+// react to it, the same way it does on a leadership takeover.
+type reconnectNotifier interface {
+	// ReconnectCount returns how many times the client has reconnected
+	// since it was created.
+	ReconnectCount() uint64
+}
+
+// clientFactory opens a fresh streamClient seeking from the given ledger
+// height, used to re-establish the orderer stream when this peer takes over
+// as leader.
+type clientFactory func(ledgerHeight uint64) (streamClient, error)
+
+// BlocksRequester abstracts the ability to pull a bounded range of blocks
+// from the ordering service on a side channel, decoupled from the live
+// delivery stream. It is used to backfill gaps discovered in DeliverBlocks
+// without disturbing the push-based BlocksDeliverer.
+type BlocksRequester interface {
+	// SeekRange issues a "specified range" seek to the ordering service for
+	// blocks [from, to] (inclusive) and returns a BlocksDeliverer which
+	// yields exactly that range.
+	SeekRange(chainID string, from, to uint64) (BlocksDeliverer, error)
+}
+
+// blockGap represents a contiguous range of block sequence numbers which
+// were skipped by the live delivery stream and still need to be pulled.
+type blockGap struct {
+	from uint64
+	to   uint64
+}
+
+// BatchConfig configures batched gossip dissemination. The zero value
+// disables batching: every block is gossiped immediately to all peers,
+// preserving the pre-batching behavior.
+type BatchConfig struct {
+	// MaxBatchSize is the number of verified blocks accumulated before a
+	// flush. Values of 0 or 1 disable batching.
+	MaxBatchSize int
+	// FlushInterval bounds how long a block can wait in the batch before
+	// being flushed, even if MaxBatchSize hasn't been reached.
+	FlushInterval time.Duration
+	// MaxFanout caps the number of peers any single batched block is
+	// gossiped to. 0 means no cap: the fan-out is ceil(log2(numberOfPeers))+k,
+	// bounded only by the number of peers on the channel.
+	MaxFanout int
+	// FanoutK is the additive constant k in the adaptive fan-out formula
+	// min(MaxFanout, ceil(log2(numberOfPeers))+k).
+	FanoutK int
+}
+
+// pendingBlock is a verified block awaiting batched dissemination.
+type pendingBlock struct {
+	ctx       context.Context
+	seqNum    uint64
+	payload   *gossip_proto.Payload
+	blockHash []byte
+}
+
 // blocksProviderImpl the actual implementation for BlocksProvider interface
 type blocksProviderImpl struct {
 	chainID string
 
 	client streamClient
 
+	requester BlocksRequester
+
+	newClient clientFactory
+
+	ledger LedgerInfo
+
+	leaderElection LeaderElection
+
+	// staticLeader preserves the pre-leader-election behavior of always
+	// pulling from the orderer and gossiping, regardless of leaderElection.
+	// It is implied by a nil leaderElection.
+	staticLeader bool
+
 	gossip GossipServiceAdapter
 
 	mcs api.MessageCryptoService
 
+	// capabilities reports whether this channel expects gossiped blocks to
+	// be signed. A nil value means signing is disabled, preserving the
+	// pre-signing unsigned envelope format.
+	capabilities CapabilityProvider
+
+	metrics Metrics
+
+	batchConfig BatchConfig
+
+	batchMu    sync.Mutex
+	batchBuf   []pendingBlock
+	batchTimer *time.Timer
+
+	// firstAfterReconnect forces the next gossiped block to bypass
+	// batching, so a peer catching up right after startup, a leadership
+	// takeover, or an internal orderer failover isn't held up waiting for a
+	// batch to fill.
+	firstAfterReconnect int32
+
+	// lastSeenReconnects is the reconnect count last observed on the active
+	// client, used by checkReconnect to detect a transparent failover.
+	// Only ever touched from the single pullAndDisseminate goroutine.
+	lastSeenReconnects uint64
+
+	mutex sync.Mutex
+
+	// lastDelivered is the highest sequence number received on the live
+	// stream and handed to AddPayload. The first block received sets it;
+	// a later block that skips past lastDelivered+1 has the skipped range
+	// enqueued as a gap and still moves lastDelivered forward. Only
+	// meaningful once haveLastDelivered is true: block 0 is a legitimate
+	// sequence number (the genesis block), so it can't double as "unset".
+	lastDelivered     uint64
+	haveLastDelivered bool
+
+	// gaps holds the ranges still pending backfill, kept sorted and
+	// coalesced so overlapping or adjacent requests merge into one.
+	gaps []blockGap
+
+	// gossiped tracks sequence numbers already disseminated so that
+	// backfilled blocks which race with the live stream aren't re-gossiped.
+	// Pruned by pruneGossiped as gaps are closed, so it stays bounded by the
+	// outstanding backfill debt rather than growing for the life of the
+	// process.
+	gossiped map[uint64]bool
+
+	gapSignal chan struct{}
+
 	done int32
+
+	// leadershipLost is set just before the orderer stream is closed as a
+	// result of losing leadership, so DeliverBlocks can tell that closure
+	// apart from a genuine stream error.
+	leadershipLost int32
 }
 
 var logger *logging.Logger // package-level logger
@@ -101,25 +283,104 @@ func init() {
 }
 
 // NewBlocksProvider constructor function to create blocks deliverer instance
-func NewBlocksProvider(chainID string, client streamClient, gossip GossipServiceAdapter, mcs api.MessageCryptoService) BlocksProvider {
+//
+// requester may be nil, in which case gaps detected in the live stream are
+// logged but not backfilled. leaderElection may be nil, in which case the
+// provider runs in static-leader mode: it behaves as if it were always
+// leader, preserving the pre-leader-election behavior. newClient is used to
+// open a fresh stream, seeking from the current ledger height, whenever
+// this peer takes over as leader; it is unused in static-leader mode.
+// capabilities may be nil, in which case gossiped blocks are disseminated
+// unsigned, preserving the pre-signing wire format. metrics may be nil, in
+// which case a no-op Metrics is used. The zero value of batchConfig
+// disables batching and adaptive fan-out, preserving the pre-batching
+// behavior of gossiping each block immediately to every peer.
+func NewBlocksProvider(chainID string, client streamClient, requester BlocksRequester, newClient clientFactory, ledger LedgerInfo, leaderElection LeaderElection, gossip GossipServiceAdapter, mcs api.MessageCryptoService, capabilities CapabilityProvider, metrics Metrics, batchConfig BatchConfig) BlocksProvider {
+	if metrics == nil {
+		metrics = NewNoopMetrics()
+	}
 	return &blocksProviderImpl{
-		chainID: chainID,
-		client:  client,
-		gossip:  gossip,
-		mcs:     mcs,
+		chainID:             chainID,
+		client:              client,
+		requester:           requester,
+		newClient:           newClient,
+		ledger:              ledger,
+		leaderElection:      leaderElection,
+		staticLeader:        leaderElection == nil,
+		gossip:              gossip,
+		mcs:                 mcs,
+		capabilities:        capabilities,
+		metrics:             metrics,
+		batchConfig:         batchConfig,
+		firstAfterReconnect: 1,
+		gossiped:            make(map[uint64]bool),
+		gapSignal:           make(chan struct{}, 1),
 	}
 }
 
 // DeliverBlocks used to pull out blocks from the ordering service to
-// distributed them across peers
-func (b *blocksProviderImpl) DeliverBlocks() {
-	defer b.client.Close()
+// distributed them across peers. In static-leader mode (the default when no
+// LeaderElection is supplied) this peer always pulls and gossips, matching
+// the pre-leader-election behavior. Otherwise, it pulls and gossips only
+// while it holds leadership for the channel, standing by in between.
+func (b *blocksProviderImpl) DeliverBlocks(ctx context.Context) {
+	defer b.getClient().Close()
+	go b.drainGaps(ctx)
+
+	if b.staticLeader {
+		b.pullAndDisseminate(ctx)
+		return
+	}
+
+	changes := make(chan bool, 1)
+	b.leaderElection.SubscribeLeadershipChanges(changes)
+
+	for !b.isDone() {
+		if !b.leaderElection.IsLeader() {
+			logger.Debugf("Not leader for chain %s, standing by", b.chainID)
+			<-changes
+			continue
+		}
+
+		logger.Infof("Became leader for chain %s, opening stream to ordering service", b.chainID)
+		if err := b.openLeaderStream(); err != nil {
+			logger.Errorf("Failed opening orderer stream for chain %s: %s", b.chainID, err)
+			return
+		}
+		b.metrics.StreamReconnects(b.chainID)
+
+		stop := b.watchForLeadershipLoss(changes)
+		b.pullAndDisseminate(ctx)
+		close(stop)
+
+		if atomic.CompareAndSwapInt32(&b.leadershipLost, 1, 0) {
+			logger.Infof("Lost leadership for chain %s, standing by", b.chainID)
+			continue
+		}
+		return
+	}
+}
+
+// pullAndDisseminate runs the live receive loop against the current stream,
+// verifying and gossiping every block. A reconnecting streamClient (see
+// NewReconnectingClient) already absorbs transient failures on its own,
+// backing off between attempts, before ever returning an error to its
+// caller; by the time Recv returns an error here it is terminal, whether
+// that's ErrOrderersExhausted, a plain streamClient's connection error, or
+// the stream being closed intentionally by watchForLeadershipLoss or Stop.
+// So any Recv error ends the loop; the caller decides what to do next.
+func (b *blocksProviderImpl) pullAndDisseminate(ctx context.Context) {
 	for !b.isDone() {
-		msg, err := b.client.Recv()
+		msg, err := b.getClient().Recv()
 		if err != nil {
-			logger.Warningf("Receive error: %s", err.Error())
+			if err == ErrOrderersExhausted {
+				logger.Errorf("Giving up on chain %s: %s", b.chainID, err)
+			} else {
+				logger.Warningf("Receive error: %s", err.Error())
+			}
 			return
 		}
+		b.checkReconnect()
 		switch t := msg.Type.(type) {
 		case *orderer.DeliverResponse_Status:
 			if t.Status == common.Status_SUCCESS {
@@ -129,30 +390,37 @@ func (b *blocksProviderImpl) DeliverBlocks() {
 			logger.Warning("Got error ", t)
 		case *orderer.DeliverResponse_Block:
 			seqNum := t.Block.Header.Number
+			b.metrics.BlocksReceived(b.chainID)
+
+			blockCtx, span := tracer.Start(ctx, "blocksprovider.ReceiveBlock", trace.WithAttributes(
+				attribute.String("channel", b.chainID),
+				attribute.Int64("blockNumber", int64(seqNum)),
+			))
 
 			marshaledBlock, err := proto.Marshal(t.Block)
 			if err != nil {
 				logger.Errorf("Error serializing block with sequence number %d, due to %s", seqNum, err)
+				span.End()
 				continue
 			}
 			if err := b.mcs.VerifyBlock(gossipcommon.ChainID(b.chainID), seqNum, marshaledBlock); err != nil {
 				logger.Errorf("Error verifying block with sequnce number %d, due to %s", seqNum, err)
+				b.metrics.VerifyFailures(b.chainID)
+				span.End()
 				continue
 			}
+			b.metrics.BlocksVerified(b.chainID)
 
-			numberOfPeers := len(b.gossip.PeersOfChannel(gossipcommon.ChainID(b.chainID)))
-			// Create payload with a block received
-			payload := createPayload(seqNum, marshaledBlock)
-			// Use payload to create gossip message
-			gossipMsg := createGossipMsg(b.chainID, payload)
-
-			logger.Debugf("Adding payload locally, buffer seqNum = [%d], peers number [%d]", seqNum, numberOfPeers)
-			// Add payload to local state payloads buffer
-			b.gossip.AddPayload(b.chainID, payload)
+			blockHash, err := blockHeaderHash(t.Block)
+			if err != nil {
+				logger.Errorf("Error hashing header of block with sequence number %d, due to %s", seqNum, err)
+				span.End()
+				continue
+			}
 
-			// Gossip messages with other nodes
-			logger.Debugf("Gossiping block [%d], peers number [%d]", seqNum, numberOfPeers)
-			b.gossip.Gossip(gossipMsg)
+			b.noteGap(seqNum)
+			b.deliver(blockCtx, seqNum, marshaledBlock, blockHash)
+			span.End()
 		default:
 			logger.Warning("Received unknown: ", t)
 			return
@@ -160,10 +428,413 @@ func (b *blocksProviderImpl) DeliverBlocks() {
 	}
 }
 
+// checkReconnect detects whether the active client transparently
+// reconnected to a different orderer endpoint since the last check and, if
+// so, marks the next block to bypass batching the same way a leadership
+// takeover does, so a peer isn't left waiting behind a batch window after a
+// failover. Only called from pullAndDisseminate's single goroutine.
+func (b *blocksProviderImpl) checkReconnect() {
+	rn, ok := b.getClient().(reconnectNotifier)
+	if !ok {
+		return
+	}
+	if count := rn.ReconnectCount(); count != b.lastSeenReconnects {
+		b.lastSeenReconnects = count
+		atomic.StoreInt32(&b.firstAfterReconnect, 1)
+		b.metrics.StreamReconnects(b.chainID)
+	}
+}
+
+// getClient returns the current stream client, which may be replaced
+// across leadership transitions.
+func (b *blocksProviderImpl) getClient() streamClient {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.client
+}
+
+// openLeaderStream opens a fresh orderer stream seeking from the current
+// ledger height and installs it as the provider's active client.
+func (b *blocksProviderImpl) openLeaderStream() error {
+	height, err := b.ledger.LedgerHeight()
+	if err != nil {
+		return err
+	}
+	client, err := b.newClient(height)
+	if err != nil {
+		return err
+	}
+	b.mutex.Lock()
+	b.client = client
+	b.mutex.Unlock()
+	b.lastSeenReconnects = 0
+	atomic.StoreInt32(&b.firstAfterReconnect, 1)
+	return nil
+}
+
+// watchForLeadershipLoss watches for leadership change notifications until
+// leadership is lost or the caller no longer needs to watch, since this
+// peer may be renotified that it's still leader (e.g. a replayed
+// notification) any number of times before it actually loses leadership. On
+// loss, it marks it and cleanly closes the active stream so
+// pullAndDisseminate returns without the provider being marked done. The
+// returned channel should be closed once the caller no longer needs to
+// watch, to release the goroutine.
+func (b *blocksProviderImpl) watchForLeadershipLoss(changes chan bool) chan struct{} {
+	stop := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case isLeader := <-changes:
+				if !isLeader {
+					atomic.StoreInt32(&b.leadershipLost, 1)
+					b.getClient().Close()
+					return
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return stop
+}
+
+// RequestBlocks enqueues the range [from, to] for backfill, coalescing it
+// with any overlapping or adjacent gap already pending.
+func (b *blocksProviderImpl) RequestBlocks(from, to uint64) {
+	if from > to {
+		return
+	}
+	b.mutex.Lock()
+	b.enqueueGap(blockGap{from: from, to: to})
+	b.mutex.Unlock()
+
+	select {
+	case b.gapSignal <- struct{}{}:
+	default:
+	}
+}
+
+// noteGap compares seqNum against the highest sequence number delivered so
+// far by the live stream and, if a hole is found, enqueues it for backfill.
+func (b *blocksProviderImpl) noteGap(seqNum uint64) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.haveLastDelivered && seqNum > b.lastDelivered+1 {
+		gap := blockGap{from: b.lastDelivered + 1, to: seqNum - 1}
+		logger.Warningf("Detected gap in block stream for chain %s: missing [%d, %d], enqueuing for backfill", b.chainID, gap.from, gap.to)
+		b.enqueueGap(gap)
+		select {
+		case b.gapSignal <- struct{}{}:
+		default:
+		}
+	}
+	if !b.haveLastDelivered || seqNum > b.lastDelivered {
+		b.lastDelivered = seqNum
+		b.haveLastDelivered = true
+	}
+	b.pruneGossiped()
+}
+
+// pruneGossiped evicts entries from b.gossiped that can no longer race with
+// backfill, so the map doesn't grow for the life of the process. A sequence
+// number only needs dedup tracking until it's older than every pending
+// gap: once there's no gap left that could backfill it again, it's
+// been durably delivered by exactly one path. Caller must hold b.mutex.
+func (b *blocksProviderImpl) pruneGossiped() {
+	if !b.haveLastDelivered {
+		return
+	}
+	floor := b.lastDelivered + 1
+	if len(b.gaps) > 0 {
+		floor = b.gaps[0].from
+	}
+	for seqNum := range b.gossiped {
+		if seqNum < floor {
+			delete(b.gossiped, seqNum)
+		}
+	}
+}
+
+// enqueueGap merges g into the pending gap list, coalescing overlapping or
+// adjacent ranges and keeping the result sorted by from, so nextGap can keep
+// popping the earliest pending gap off the front. Caller must hold b.mutex.
+func (b *blocksProviderImpl) enqueueGap(g blockGap) {
+	merged := make([]blockGap, 0, len(b.gaps)+1)
+	for _, existing := range b.gaps {
+		if g.from > existing.to+1 || existing.from > g.to+1 {
+			merged = append(merged, existing)
+			continue
+		}
+		if existing.from < g.from {
+			g.from = existing.from
+		}
+		if existing.to > g.to {
+			g.to = existing.to
+		}
+	}
+	merged = append(merged, g)
+	sort.Slice(merged, func(i, j int) bool { return merged[i].from < merged[j].from })
+	b.gaps = merged
+}
+
+// drainGaps runs concurrently with the live delivery loop, pulling any
+// pending gaps from the ordering service and feeding them through the same
+// verify/AddPayload path as live blocks.
+func (b *blocksProviderImpl) drainGaps(ctx context.Context) {
+	for !b.isDone() {
+		<-b.gapSignal
+
+		for {
+			gap, ok := b.nextGap()
+			if !ok {
+				break
+			}
+			b.fillGap(ctx, gap)
+
+			b.mutex.Lock()
+			b.pruneGossiped()
+			b.mutex.Unlock()
+		}
+	}
+}
+
+// nextGap pops the earliest pending gap, if any.
+func (b *blocksProviderImpl) nextGap() (blockGap, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if len(b.gaps) == 0 {
+		return blockGap{}, false
+	}
+	gap := b.gaps[0]
+	b.gaps = b.gaps[1:]
+	return gap, true
+}
+
+// fillGap pulls blocks [gap.from, gap.to] from the ordering service via the
+// BlocksRequester and feeds each through the usual verify/deliver path.
+func (b *blocksProviderImpl) fillGap(ctx context.Context, gap blockGap) {
+	if b.requester == nil {
+		logger.Warningf("No BlocksRequester configured for chain %s, cannot backfill [%d, %d]", b.chainID, gap.from, gap.to)
+		return
+	}
+
+	deliverer, err := b.requester.SeekRange(b.chainID, gap.from, gap.to)
+	if err != nil {
+		logger.Errorf("Failed requesting blocks [%d, %d] for chain %s: %s", gap.from, gap.to, b.chainID, err)
+		return
+	}
+
+	for seqNum := gap.from; seqNum <= gap.to && !b.isDone(); {
+		msg, err := deliverer.Recv()
+		if err != nil {
+			logger.Errorf("Error pulling backfill block for chain %s in range [%d, %d]: %s", b.chainID, gap.from, gap.to, err)
+			return
+		}
+		block, ok := msg.Type.(*orderer.DeliverResponse_Block)
+		if !ok {
+			logger.Warningf("Unexpected message while backfilling chain %s: %v", b.chainID, msg.Type)
+			return
+		}
+		b.metrics.BlocksReceived(b.chainID)
+
+		blockCtx, span := tracer.Start(ctx, "blocksprovider.BackfillBlock", trace.WithAttributes(
+			attribute.String("channel", b.chainID),
+			attribute.Int64("blockNumber", int64(seqNum)),
+		))
+
+		marshaledBlock, err := proto.Marshal(block.Block)
+		if err != nil {
+			logger.Errorf("Error serializing backfilled block with sequence number %d, due to %s", seqNum, err)
+			span.End()
+			return
+		}
+		if err := b.mcs.VerifyBlock(gossipcommon.ChainID(b.chainID), seqNum, marshaledBlock); err != nil {
+			logger.Errorf("Error verifying backfilled block with sequence number %d, due to %s", seqNum, err)
+			b.metrics.VerifyFailures(b.chainID)
+			span.End()
+			return
+		}
+		b.metrics.BlocksVerified(b.chainID)
+
+		blockHash, err := blockHeaderHash(block.Block)
+		if err != nil {
+			logger.Errorf("Error hashing header of backfilled block with sequence number %d, due to %s", seqNum, err)
+			span.End()
+			return
+		}
+
+		b.deliver(blockCtx, seqNum, marshaledBlock, blockHash)
+		span.End()
+		seqNum++
+	}
+}
+
+// deliver adds the block to the local state payload buffer and gossips it
+// to the rest of the channel, unless it was already disseminated earlier
+// (e.g. a backfilled block that raced with the live stream).
+func (b *blocksProviderImpl) deliver(ctx context.Context, seqNum uint64, marshaledBlock []byte, blockHash []byte) {
+	start := time.Now()
+	_, span := tracer.Start(ctx, "blocksprovider.DeliverBlock", trace.WithAttributes(
+		attribute.String("channel", b.chainID),
+		attribute.Int64("blockNumber", int64(seqNum)),
+	))
+	defer func() {
+		b.metrics.BlockProcessingSeconds(b.chainID, time.Since(start).Seconds())
+		span.End()
+	}()
+
+	b.mutex.Lock()
+	alreadyGossiped := b.gossiped[seqNum]
+	b.gossiped[seqNum] = true
+	b.mutex.Unlock()
+
+	// Create payload with a block received
+	payload := createPayload(seqNum, marshaledBlock)
+	b.metrics.LastBlockNumber(b.chainID, seqNum)
+
+	logger.Debugf("Adding payload locally, buffer seqNum = [%d]", seqNum)
+	// Add payload to local state payloads buffer
+	b.gossip.AddPayload(b.chainID, payload)
+
+	if alreadyGossiped {
+		logger.Debugf("Block [%d] already disseminated, suppressing re-gossip", seqNum)
+		return
+	}
+
+	b.disseminate(ctx, seqNum, payload, blockHash)
+}
+
+// disseminate gossips the block either immediately, to every peer, or by
+// enqueuing it into the current batch window, depending on batchConfig. The
+// first block after startup or a leadership takeover always bypasses
+// batching to minimize catch-up latency for lagging peers.
+func (b *blocksProviderImpl) disseminate(ctx context.Context, seqNum uint64, payload *gossip_proto.Payload, blockHash []byte) {
+	if b.batchConfig.MaxBatchSize <= 1 || atomic.CompareAndSwapInt32(&b.firstAfterReconnect, 1, 0) {
+		b.gossipImmediate(seqNum, payload, blockHash)
+		return
+	}
+	b.enqueueForBatch(ctx, seqNum, payload, blockHash)
+}
+
+// gossipImmediate builds the block's envelope (signed only when the channel's
+// signed-gossip-blocks capability is enabled, see createGossipMsg) and
+// disseminates it to every peer on the channel, bypassing adaptive fan-out
+// and batching.
+func (b *blocksProviderImpl) gossipImmediate(seqNum uint64, payload *gossip_proto.Payload, blockHash []byte) {
+	numberOfPeers := len(b.gossip.PeersOfChannel(gossipcommon.ChainID(b.chainID)))
+	env, err := b.createGossipMsg(blockHash, payload)
+	if err != nil {
+		logger.Errorf("Error creating gossip envelope for block [%d]: %s", seqNum, err)
+		return
+	}
+	logger.Debugf("Gossiping block [%d], peers number [%d]", seqNum, numberOfPeers)
+	b.metrics.GossipFanout(b.chainID, numberOfPeers)
+	b.gossip.Gossip(env)
+}
+
+// enqueueForBatch adds the block to the current batch window, starting the
+// flush timer for the first block in a window and flushing immediately
+// once MaxBatchSize is reached.
+func (b *blocksProviderImpl) enqueueForBatch(ctx context.Context, seqNum uint64, payload *gossip_proto.Payload, blockHash []byte) {
+	b.batchMu.Lock()
+	b.batchBuf = append(b.batchBuf, pendingBlock{ctx: ctx, seqNum: seqNum, payload: payload, blockHash: blockHash})
+	if len(b.batchBuf) == 1 {
+		b.batchTimer = time.AfterFunc(b.batchConfig.FlushInterval, b.flushDueBatch)
+	}
+
+	var blocks []pendingBlock
+	if len(b.batchBuf) >= b.batchConfig.MaxBatchSize {
+		if b.batchTimer != nil {
+			b.batchTimer.Stop()
+			b.batchTimer = nil
+		}
+		blocks = b.batchBuf
+		b.batchBuf = nil
+	}
+	b.batchMu.Unlock()
+
+	if blocks != nil {
+		b.gossipBatch(blocks)
+	}
+}
+
+// flushDueBatch is invoked by the flush timer when a batch window's
+// FlushInterval elapses before it fills up.
+func (b *blocksProviderImpl) flushDueBatch() {
+	b.batchMu.Lock()
+	blocks := b.batchBuf
+	b.batchBuf = nil
+	b.batchTimer = nil
+	b.batchMu.Unlock()
+
+	if len(blocks) > 0 {
+		b.gossipBatch(blocks)
+	}
+}
+
+// gossipBatch disseminates a batch of blocks to a common set of peers
+// selected once via the adaptive fan-out policy, so a burst of blocks after
+// catch-up doesn't multiply fan-out load on large channels.
+func (b *blocksProviderImpl) gossipBatch(blocks []pendingBlock) {
+	peers := b.gossip.PeersOfChannel(gossipcommon.ChainID(b.chainID))
+	fanout := adaptiveFanout(len(peers), b.batchConfig.MaxFanout, b.batchConfig.FanoutK)
+	selected := peers
+	if fanout < len(peers) {
+		selected = peers[:fanout]
+	}
+
+	for _, blk := range blocks {
+		_, span := tracer.Start(blk.ctx, "blocksprovider.GossipBatchedBlock", trace.WithAttributes(
+			attribute.String("channel", b.chainID),
+			attribute.Int64("blockNumber", int64(blk.seqNum)),
+			attribute.Int("fanout", len(selected)),
+		))
+
+		env, err := b.createGossipMsg(blk.blockHash, blk.payload)
+		if err != nil {
+			logger.Errorf("Error creating gossip envelope for batched block [%d]: %s", blk.seqNum, err)
+			span.End()
+			continue
+		}
+		logger.Debugf("Gossiping batched block [%d], peers number [%d]", blk.seqNum, len(selected))
+		b.metrics.GossipFanout(b.chainID, len(selected))
+		b.gossip.GossipToPeers(env, selected)
+		span.End()
+	}
+}
+
+// adaptiveFanout computes how many peers a single block should be gossiped
+// to: min(maxFanout, ceil(log2(numberOfPeers))+k), so large channels don't
+// see fan-out grow linearly with membership. maxFanout of 0 means uncapped.
+// The result is never less than 1, nor more than numberOfPeers; it is 0
+// only when there are no peers.
+func adaptiveFanout(numberOfPeers, maxFanout, k int) int {
+	if numberOfPeers <= 0 {
+		return 0
+	}
+	fanout := int(math.Ceil(math.Log2(float64(numberOfPeers)))) + k
+	if fanout < 1 {
+		fanout = 1
+	}
+	if maxFanout > 0 && fanout > maxFanout {
+		fanout = maxFanout
+	}
+	if fanout > numberOfPeers {
+		fanout = numberOfPeers
+	}
+	return fanout
+}
+
 // Stop stops blocks delivery provider
 func (b *blocksProviderImpl) Stop() {
 	atomic.StoreInt32(&b.done, 1)
-	b.client.Close()
+	b.getClient().Close()
+	select {
+	case b.gapSignal <- struct{}{}:
+	default:
+	}
 }
 
 // Check whenever provider is stopped
@@ -171,18 +842,45 @@ func (b *blocksProviderImpl) isDone() bool {
 	return atomic.LoadInt32(&b.done) == 1
 }
 
-func createGossipMsg(chainID string, payload *gossip_proto.Payload) *gossip_proto.GossipMessage {
+// createGossipMsg builds the GossipMessage carrying payload and returns it
+// as an envelope: signed, with the signature bound to blockHash, when this
+// channel's signed-gossip-blocks capability is enabled; otherwise as an
+// unsigned envelope, preserving the pre-signing wire format.
+func (b *blocksProviderImpl) createGossipMsg(blockHash []byte, payload *gossip_proto.Payload) (*gossip_proto.Envelope, error) {
+	nonce, err := randomNonce()
+	if err != nil {
+		return nil, err
+	}
 	gossipMsg := &gossip_proto.GossipMessage{
-		Nonce:   0,
+		Nonce:   nonce,
 		Tag:     gossip_proto.GossipMessage_CHAN_AND_ORG,
-		Channel: []byte(chainID),
+		Channel: []byte(b.chainID),
 		Content: &gossip_proto.GossipMessage_DataMsg{
 			DataMsg: &gossip_proto.DataMessage{
 				Payload: payload,
 			},
 		},
 	}
-	return gossipMsg
+
+	if b.capabilities == nil || !b.capabilities.SignedGossipBlocks() {
+		marshaledMsg, err := proto.Marshal(gossipMsg)
+		if err != nil {
+			return nil, err
+		}
+		return &gossip_proto.Envelope{Payload: marshaledMsg}, nil
+	}
+
+	return b.gossip.SignMessage(gossipMsg, blockHash)
+}
+
+// randomNonce returns a cryptographically random uint64, suitable for a
+// signed gossip envelope's anti-replay nonce.
+func randomNonce() (uint64, error) {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(b[:]), nil
 }
 
 func createPayload(seqNum uint64, marshaledBlock []byte) *gossip_proto.Payload {
@@ -191,3 +889,14 @@ func createPayload(seqNum uint64, marshaledBlock []byte) *gossip_proto.Payload {
 		SeqNum: seqNum,
 	}
 }
+
+// blockHeaderHash computes the hash of a block's header, which uniquely
+// identifies the block and is cheap to verify ahead of the full VerifyBlock.
+func blockHeaderHash(block *common.Block) ([]byte, error) {
+	headerBytes, err := proto.Marshal(block.Header)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(headerBytes)
+	return sum[:], nil
+}
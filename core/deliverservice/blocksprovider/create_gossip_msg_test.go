@@ -0,0 +1,156 @@
+/*
+Copyright IBM Corp. 2017 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package blocksprovider
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	gossipcommon "github.com/hyperledger/fabric/gossip/common"
+	"github.com/hyperledger/fabric/gossip/discovery"
+	gossip_proto "github.com/hyperledger/fabric/protos/gossip"
+)
+
+// fakeCapabilityProvider reports a fixed SignedGossipBlocks answer.
+type fakeCapabilityProvider struct{ signed bool }
+
+func (f fakeCapabilityProvider) SignedGossipBlocks() bool { return f.signed }
+
+// fakeSigningGossipAdapter records SignMessage calls; the rest of
+// GossipServiceAdapter is unused by createGossipMsg.
+type fakeSigningGossipAdapter struct {
+	signCalls int
+	lastMsg   *gossip_proto.GossipMessage
+	lastHash  []byte
+	signErr   error
+}
+
+func (f *fakeSigningGossipAdapter) PeersOfChannel(gossipcommon.ChainID) []discovery.NetworkMember {
+	return nil
+}
+func (f *fakeSigningGossipAdapter) AddPayload(string, *gossip_proto.Payload) error { return nil }
+func (f *fakeSigningGossipAdapter) Gossip(*gossip_proto.Envelope)                  {}
+func (f *fakeSigningGossipAdapter) GossipToPeers(*gossip_proto.Envelope, []discovery.NetworkMember) {
+}
+
+func (f *fakeSigningGossipAdapter) SignMessage(msg *gossip_proto.GossipMessage, blockHash []byte) (*gossip_proto.Envelope, error) {
+	f.signCalls++
+	f.lastMsg = msg
+	f.lastHash = blockHash
+	if f.signErr != nil {
+		return nil, f.signErr
+	}
+	return &gossip_proto.Envelope{Payload: []byte("signed-envelope")}, nil
+}
+
+func unmarshalGossipMessage(t *testing.T, env *gossip_proto.Envelope) *gossip_proto.GossipMessage {
+	t.Helper()
+	msg := &gossip_proto.GossipMessage{}
+	if err := proto.Unmarshal(env.Payload, msg); err != nil {
+		t.Fatalf("failed unmarshaling gossip message: %s", err)
+	}
+	return msg
+}
+
+func TestCreateGossipMsgUnsignedWhenCapabilitiesNil(t *testing.T) {
+	b := &blocksProviderImpl{chainID: "mychannel"}
+	payload := &gossip_proto.Payload{SeqNum: 5, Data: []byte("block")}
+
+	env, err := b.createGossipMsg([]byte("hash"), payload)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	msg := unmarshalGossipMessage(t, env)
+	if !bytes.Equal(msg.Channel, []byte("mychannel")) {
+		t.Fatalf("expected channel %q, got %q", "mychannel", msg.Channel)
+	}
+	if msg.Tag != gossip_proto.GossipMessage_CHAN_AND_ORG {
+		t.Fatalf("expected tag CHAN_AND_ORG, got %v", msg.Tag)
+	}
+	dataMsg, ok := msg.Content.(*gossip_proto.GossipMessage_DataMsg)
+	if !ok || dataMsg.DataMsg.Payload.SeqNum != payload.SeqNum {
+		t.Fatalf("expected the envelope to carry the given payload, got %v", msg.Content)
+	}
+}
+
+func TestCreateGossipMsgUnsignedWhenCapabilityDisabled(t *testing.T) {
+	gossip := &fakeSigningGossipAdapter{}
+	b := &blocksProviderImpl{chainID: "mychannel", capabilities: fakeCapabilityProvider{signed: false}, gossip: gossip}
+
+	if _, err := b.createGossipMsg([]byte("hash"), &gossip_proto.Payload{SeqNum: 1}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if gossip.signCalls != 0 {
+		t.Fatalf("expected SignMessage not to be called when the capability is disabled, got %d calls", gossip.signCalls)
+	}
+}
+
+func TestCreateGossipMsgSignsAndBindsBlockHashWhenCapabilityEnabled(t *testing.T) {
+	gossip := &fakeSigningGossipAdapter{}
+	b := &blocksProviderImpl{chainID: "mychannel", capabilities: fakeCapabilityProvider{signed: true}, gossip: gossip}
+	blockHash := []byte("the-block-hash")
+	payload := &gossip_proto.Payload{SeqNum: 9}
+
+	env, err := b.createGossipMsg(blockHash, payload)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if gossip.signCalls != 1 {
+		t.Fatalf("expected SignMessage to be called exactly once, got %d", gossip.signCalls)
+	}
+	if !bytes.Equal(gossip.lastHash, blockHash) {
+		t.Fatalf("expected the signature to be bound to %q, got %q", blockHash, gossip.lastHash)
+	}
+	if gossip.lastMsg.Content.(*gossip_proto.GossipMessage_DataMsg).DataMsg.Payload.SeqNum != payload.SeqNum {
+		t.Fatal("expected the signed message to carry the given payload")
+	}
+	if !bytes.Equal(env.Payload, []byte("signed-envelope")) {
+		t.Fatal("expected createGossipMsg to return the envelope produced by SignMessage")
+	}
+}
+
+func TestCreateGossipMsgPropagatesSigningError(t *testing.T) {
+	signErr := errors.New("signing failed")
+	gossip := &fakeSigningGossipAdapter{signErr: signErr}
+	b := &blocksProviderImpl{chainID: "mychannel", capabilities: fakeCapabilityProvider{signed: true}, gossip: gossip}
+
+	if _, err := b.createGossipMsg([]byte("hash"), &gossip_proto.Payload{}); err != signErr {
+		t.Fatalf("expected signing error to propagate, got %v", err)
+	}
+}
+
+func TestCreateGossipMsgNonceIsRandomPerCall(t *testing.T) {
+	b := &blocksProviderImpl{chainID: "mychannel"}
+
+	env1, err := b.createGossipMsg([]byte("hash"), &gossip_proto.Payload{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	env2, err := b.createGossipMsg([]byte("hash"), &gossip_proto.Payload{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	nonce1 := unmarshalGossipMessage(t, env1).Nonce
+	nonce2 := unmarshalGossipMessage(t, env2).Nonce
+	if nonce1 == nonce2 {
+		t.Fatalf("expected distinct nonces across calls, got the same value %d twice", nonce1)
+	}
+}
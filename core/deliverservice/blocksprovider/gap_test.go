@@ -0,0 +1,88 @@
+/*
+Copyright IBM Corp. 2017 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package blocksprovider
+
+import "testing"
+
+func newTestProvider() *blocksProviderImpl {
+	return &blocksProviderImpl{gapSignal: make(chan struct{}, 1)}
+}
+
+func TestNoteGapGenesisBlockIsNotMistakenForUnset(t *testing.T) {
+	b := newTestProvider()
+
+	// Block 0, the genesis block, is a legitimate first delivery and must
+	// not be treated as "no baseline yet".
+	b.noteGap(0)
+	if !b.haveLastDelivered || b.lastDelivered != 0 {
+		t.Fatalf("expected haveLastDelivered=true, lastDelivered=0, got haveLastDelivered=%v, lastDelivered=%d", b.haveLastDelivered, b.lastDelivered)
+	}
+
+	// A gap right after genesis should now be detected rather than ignored.
+	b.noteGap(3)
+	if len(b.gaps) != 1 || b.gaps[0] != (blockGap{from: 1, to: 2}) {
+		t.Fatalf("expected gap [1,2] to be enqueued, got %v", b.gaps)
+	}
+}
+
+func TestNoteGapNoFalsePositiveOnFirstBlock(t *testing.T) {
+	b := newTestProvider()
+
+	b.noteGap(42)
+	if len(b.gaps) != 0 {
+		t.Fatalf("expected no gap on the very first delivered block, got %v", b.gaps)
+	}
+	if !b.haveLastDelivered || b.lastDelivered != 42 {
+		t.Fatalf("expected lastDelivered=42, got %d", b.lastDelivered)
+	}
+}
+
+func TestEnqueueGapKeepsGapsSorted(t *testing.T) {
+	b := newTestProvider()
+
+	b.enqueueGap(blockGap{from: 10, to: 12})
+	b.enqueueGap(blockGap{from: 1, to: 2})
+	b.enqueueGap(blockGap{from: 5, to: 6})
+
+	want := []blockGap{{from: 1, to: 2}, {from: 5, to: 6}, {from: 10, to: 12}}
+	if len(b.gaps) != len(want) {
+		t.Fatalf("expected %v, got %v", want, b.gaps)
+	}
+	for i := range want {
+		if b.gaps[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, b.gaps)
+		}
+	}
+
+	// nextGap must pop the earliest gap, relying on this order.
+	gap, ok := b.nextGap()
+	if !ok || gap != (blockGap{from: 1, to: 2}) {
+		t.Fatalf("expected nextGap to return the earliest gap [1,2], got %v, %v", gap, ok)
+	}
+}
+
+func TestEnqueueGapCoalescesAdjacentAndOverlappingRanges(t *testing.T) {
+	b := newTestProvider()
+
+	b.enqueueGap(blockGap{from: 1, to: 5})
+	b.enqueueGap(blockGap{from: 6, to: 8})  // adjacent, should merge
+	b.enqueueGap(blockGap{from: 4, to: 10}) // overlapping, should merge
+
+	if len(b.gaps) != 1 || b.gaps[0] != (blockGap{from: 1, to: 10}) {
+		t.Fatalf("expected a single merged gap [1,10], got %v", b.gaps)
+	}
+}
@@ -0,0 +1,94 @@
+/*
+Copyright IBM Corp. 2017 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package blocksprovider
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/orderer"
+)
+
+// erroringStreamClient always fails Recv, counting how many times it was
+// called so tests can assert pullAndDisseminate doesn't busy-loop on it.
+type erroringStreamClient struct {
+	recvCalls int32
+	closed    int32
+}
+
+func (c *erroringStreamClient) Recv() (*orderer.DeliverResponse, error) {
+	atomic.AddInt32(&c.recvCalls, 1)
+	return nil, errors.New("simulated connection error")
+}
+
+func (c *erroringStreamClient) Send(*common.Envelope) error { return nil }
+
+func (c *erroringStreamClient) Close() { atomic.StoreInt32(&c.closed, 1) }
+
+func TestPullAndDisseminateReturnsOnRecvErrorWithoutBusyLooping(t *testing.T) {
+	client := &erroringStreamClient{}
+	b := &blocksProviderImpl{client: client, gapSignal: make(chan struct{}, 1)}
+
+	done := make(chan struct{})
+	go func() {
+		b.pullAndDisseminate(context.Background())
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("pullAndDisseminate did not return after a terminal Recv error")
+	}
+
+	if calls := atomic.LoadInt32(&client.recvCalls); calls != 1 {
+		t.Fatalf("expected exactly one Recv call, got %d; a higher count means pullAndDisseminate busy-looped", calls)
+	}
+}
+
+func TestWatchForLeadershipLossIgnoresReplayedLeaderNotifications(t *testing.T) {
+	client := &erroringStreamClient{}
+	b := &blocksProviderImpl{client: client, gapSignal: make(chan struct{}, 1)}
+
+	changes := make(chan bool, 1)
+	stop := b.watchForLeadershipLoss(changes)
+	defer close(stop)
+
+	// A replayed "still leader" notification must not end the watch.
+	changes <- true
+	time.Sleep(50 * time.Millisecond)
+	if atomic.LoadInt32(&b.leadershipLost) != 0 {
+		t.Fatal("a replayed leader notification incorrectly marked leadership as lost")
+	}
+	if atomic.LoadInt32(&client.closed) != 0 {
+		t.Fatal("a replayed leader notification incorrectly closed the stream")
+	}
+
+	// The subsequent loss notification must still be observed.
+	changes <- false
+	time.Sleep(50 * time.Millisecond)
+	if atomic.LoadInt32(&b.leadershipLost) != 1 {
+		t.Fatal("expected leadershipLost to be set after a false notification")
+	}
+	if atomic.LoadInt32(&client.closed) != 1 {
+		t.Fatal("expected the stream to be closed after losing leadership")
+	}
+}
@@ -0,0 +1,67 @@
+/*
+Copyright IBM Corp. 2017 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package blocksprovider
+
+// Metrics groups the counters, histograms and gauges blocksProviderImpl
+// reports so operators can observe how far behind the orderer a channel is,
+// how many blocks fail verification, and how widely blocks fan out over
+// gossip. All methods are labeled by chainID. Use NewNoopMetrics when no
+// monitoring backend is wired up.
+type Metrics interface {
+	// BlocksReceived counts every block received from the ordering
+	// service, whether delivered live or pulled in to backfill a gap.
+	BlocksReceived(chainID string)
+
+	// BlocksVerified counts every block that passed VerifyBlock.
+	BlocksVerified(chainID string)
+
+	// VerifyFailures counts every block that failed VerifyBlock.
+	VerifyFailures(chainID string)
+
+	// GossipFanout observes the number of peers a block was gossiped to.
+	GossipFanout(chainID string, numberOfPeers int)
+
+	// BlockProcessingSeconds observes the wall-clock time spent handing a
+	// single verified block to the local payload buffer and gossiping it
+	// (or enqueuing it for a batch). Verification time is not included.
+	BlockProcessingSeconds(chainID string, seconds float64)
+
+	// StreamReconnects counts every time the orderer stream is
+	// re-established, e.g. on failover or leadership takeover.
+	StreamReconnects(chainID string)
+
+	// LastBlockNumber sets the sequence number of the most recently
+	// delivered block.
+	LastBlockNumber(chainID string, seqNum uint64)
+}
+
+// noopMetrics is the default Metrics implementation used when a caller does
+// not wire up a monitoring backend.
+type noopMetrics struct{}
+
+// NewNoopMetrics returns a Metrics implementation whose methods are no-ops.
+func NewNoopMetrics() Metrics {
+	return noopMetrics{}
+}
+
+func (noopMetrics) BlocksReceived(chainID string)                       {}
+func (noopMetrics) BlocksVerified(chainID string)                       {}
+func (noopMetrics) VerifyFailures(chainID string)                       {}
+func (noopMetrics) GossipFanout(chainID string, numberOfPeers int)      {}
+func (noopMetrics) BlockProcessingSeconds(chainID string, secs float64) {}
+func (noopMetrics) StreamReconnects(chainID string)                     {}
+func (noopMetrics) LastBlockNumber(chainID string, seqNum uint64)       {}
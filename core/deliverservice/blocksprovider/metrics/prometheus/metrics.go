@@ -0,0 +1,122 @@
+/*
+Copyright IBM Corp. 2017 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package prometheus provides a Prometheus-backed implementation of
+// blocksprovider.Metrics.
+package prometheus
+
+import (
+	"github.com/hyperledger/fabric/core/deliverservice/blocksprovider"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "blocksprovider"
+
+// Metrics is a Prometheus-backed implementation of blocksprovider.Metrics.
+type Metrics struct {
+	blocksReceived         *prometheus.CounterVec
+	blocksVerified         *prometheus.CounterVec
+	verifyFailures         *prometheus.CounterVec
+	gossipFanout           *prometheus.HistogramVec
+	blockProcessingSeconds *prometheus.HistogramVec
+	streamReconnects       *prometheus.CounterVec
+	lastBlockNumber        *prometheus.GaugeVec
+}
+
+// NewMetrics constructs a Metrics and registers its collectors with reg.
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		blocksReceived: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "blocks_received_total",
+			Help:      "Total number of blocks received from the ordering service, live or backfilled.",
+		}, []string{"channel"}),
+		blocksVerified: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "blocks_verified_total",
+			Help:      "Total number of blocks that passed verification.",
+		}, []string{"channel"}),
+		verifyFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "verify_failures_total",
+			Help:      "Total number of blocks that failed verification.",
+		}, []string{"channel"}),
+		gossipFanout: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "gossip_fanout_peers",
+			Help:      "Number of peers a block was gossiped to.",
+			Buckets:   prometheus.LinearBuckets(0, 5, 10),
+		}, []string{"channel"}),
+		blockProcessingSeconds: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "block_processing_seconds",
+			Help:      "Time spent adding a verified block locally and gossiping or batching it.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"channel"}),
+		streamReconnects: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "stream_reconnects_total",
+			Help:      "Total number of times the orderer stream was re-established.",
+		}, []string{"channel"}),
+		lastBlockNumber: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "last_block_number",
+			Help:      "Sequence number of the most recently delivered block.",
+		}, []string{"channel"}),
+	}
+
+	reg.MustRegister(
+		m.blocksReceived,
+		m.blocksVerified,
+		m.verifyFailures,
+		m.gossipFanout,
+		m.blockProcessingSeconds,
+		m.streamReconnects,
+		m.lastBlockNumber,
+	)
+
+	return m
+}
+
+func (m *Metrics) BlocksReceived(chainID string) {
+	m.blocksReceived.WithLabelValues(chainID).Inc()
+}
+
+func (m *Metrics) BlocksVerified(chainID string) {
+	m.blocksVerified.WithLabelValues(chainID).Inc()
+}
+
+func (m *Metrics) VerifyFailures(chainID string) {
+	m.verifyFailures.WithLabelValues(chainID).Inc()
+}
+
+func (m *Metrics) GossipFanout(chainID string, numberOfPeers int) {
+	m.gossipFanout.WithLabelValues(chainID).Observe(float64(numberOfPeers))
+}
+
+func (m *Metrics) BlockProcessingSeconds(chainID string, seconds float64) {
+	m.blockProcessingSeconds.WithLabelValues(chainID).Observe(seconds)
+}
+
+func (m *Metrics) StreamReconnects(chainID string) {
+	m.streamReconnects.WithLabelValues(chainID).Inc()
+}
+
+func (m *Metrics) LastBlockNumber(chainID string, seqNum uint64) {
+	m.lastBlockNumber.WithLabelValues(chainID).Set(float64(seqNum))
+}
+
+var _ blocksprovider.Metrics = (*Metrics)(nil)
@@ -0,0 +1,91 @@
+/*
+Copyright IBM Corp. 2017 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package blocksprovider
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+func TestNewNoopMetricsIsSafeAndSideEffectFree(t *testing.T) {
+	m := NewNoopMetrics()
+	m.BlocksReceived("chain")
+	m.BlocksVerified("chain")
+	m.VerifyFailures("chain")
+	m.GossipFanout("chain", 5)
+	m.BlockProcessingSeconds("chain", 1.5)
+	m.StreamReconnects("chain")
+	m.LastBlockNumber("chain", 42)
+}
+
+func TestNewBlocksProviderDefaultsToNoopMetricsWhenNilSupplied(t *testing.T) {
+	provider := NewBlocksProvider("chain", nil, nil, nil, nil, nil, nil, nil, nil, nil, BatchConfig{})
+
+	impl, ok := provider.(*blocksProviderImpl)
+	if !ok {
+		t.Fatalf("expected *blocksProviderImpl, got %T", provider)
+	}
+	if _, ok := impl.metrics.(noopMetrics); !ok {
+		t.Fatalf("expected metrics to default to noopMetrics, got %T", impl.metrics)
+	}
+}
+
+// fakeMetrics records how many times StreamReconnects fired and the chainID
+// it was last reported for; its other methods are no-ops.
+type fakeMetrics struct {
+	streamReconnects int
+	lastChainID      string
+}
+
+func (f *fakeMetrics) BlocksReceived(string)               {}
+func (f *fakeMetrics) BlocksVerified(string)                {}
+func (f *fakeMetrics) VerifyFailures(string)                {}
+func (f *fakeMetrics) GossipFanout(string, int)              {}
+func (f *fakeMetrics) BlockProcessingSeconds(string, float64) {}
+func (f *fakeMetrics) StreamReconnects(chainID string) {
+	f.streamReconnects++
+	f.lastChainID = chainID
+}
+func (f *fakeMetrics) LastBlockNumber(string, uint64) {}
+
+func TestCheckReconnectReportsStreamReconnectsOncePerFailover(t *testing.T) {
+	client := &reconnectCountingClient{}
+	metrics := &fakeMetrics{}
+	b := &blocksProviderImpl{chainID: "mychannel", client: client, metrics: metrics, gapSignal: make(chan struct{}, 1)}
+
+	atomic.StoreUint64(&client.count, 1)
+	b.checkReconnect()
+	if metrics.streamReconnects != 1 {
+		t.Fatalf("expected StreamReconnects to be reported once after a failover, got %d", metrics.streamReconnects)
+	}
+	if metrics.lastChainID != "mychannel" {
+		t.Fatalf("expected StreamReconnects to be labeled with the chain ID, got %q", metrics.lastChainID)
+	}
+
+	// An already-observed reconnect count must not be re-reported.
+	b.checkReconnect()
+	if metrics.streamReconnects != 1 {
+		t.Fatalf("expected StreamReconnects not to be re-reported for an unchanged reconnect count, got %d", metrics.streamReconnects)
+	}
+
+	// A further failover must be reported again.
+	atomic.StoreUint64(&client.count, 2)
+	b.checkReconnect()
+	if metrics.streamReconnects != 2 {
+		t.Fatalf("expected a second failover to report StreamReconnects again, got %d", metrics.streamReconnects)
+	}
+}
@@ -0,0 +1,254 @@
+/*
+Copyright IBM Corp. 2017 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package blocksprovider
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/orderer"
+)
+
+// ErrOrderersExhausted is returned by a reconnecting streamClient when every
+// known orderer endpoint has failed past the configured retry budget.
+var ErrOrderersExhausted = errors.New("blocksprovider: all orderer endpoints exhausted")
+
+// BackoffConfig configures the exponential backoff applied between
+// reconnect attempts against successive orderer endpoints.
+type BackoffConfig struct {
+	// Base is the delay before the first retry.
+	Base time.Duration
+	// Cap bounds the delay of any single retry.
+	Cap time.Duration
+	// Jitter is the fraction (0, 1] of the computed delay to randomize.
+	Jitter float64
+	// MaxRetries is the number of retry cycles through the full endpoint
+	// list before giving up with ErrOrderersExhausted. Zero means retry
+	// forever.
+	MaxRetries int
+}
+
+// DefaultBackoffConfig is used when a caller does not need to customize
+// reconnect behavior.
+var DefaultBackoffConfig = BackoffConfig{
+	Base:   500 * time.Millisecond,
+	Cap:    30 * time.Second,
+	Jitter: 0.2,
+}
+
+// dialer connects to a single orderer endpoint and returns a streamClient
+// seeking blocks starting at seq.
+type dialer func(endpoint string, seq uint64) (streamClient, error)
+
+// reconnectingClient implements streamClient over a pool of orderer
+// endpoints. Whenever the active connection errors out, it fails over to
+// the next endpoint in round-robin order, backing off exponentially between
+// attempts, until it reconnects or exhausts its retry budget.
+type reconnectingClient struct {
+	endpoints    []string
+	dial         dialer
+	ledger       LedgerInfo
+	backoff      BackoffConfig
+	onDisconnect func(endpoint string, err error)
+
+	mutex           sync.Mutex
+	next            int
+	current         streamClient
+	currentEndpoint string
+	closed          bool
+
+	// reconnects counts every successful failover to a new endpoint, so
+	// callers can detect a transparent reconnect via ReconnectCount.
+	reconnects uint64
+}
+
+// NewReconnectingClient dials the first endpoint in endpoints and returns a
+// streamClient which transparently fails over to the next endpoint, with
+// exponential backoff, whenever the active connection errors out.
+// onDisconnect, which may be nil, is invoked with the failed endpoint and
+// error every time a failover is triggered, so operators can observe it.
+func NewReconnectingClient(endpoints []string, dial dialer, ledger LedgerInfo, backoff BackoffConfig, onDisconnect func(endpoint string, err error)) (streamClient, error) {
+	if len(endpoints) == 0 {
+		return nil, errors.New("blocksprovider: no orderer endpoints configured")
+	}
+
+	height, err := ledger.LedgerHeight()
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := endpoints[0]
+	client, err := dial(endpoint, height)
+	if err != nil {
+		return nil, err
+	}
+
+	return &reconnectingClient{
+		endpoints:       endpoints,
+		dial:            dial,
+		ledger:          ledger,
+		backoff:         backoff,
+		onDisconnect:    onDisconnect,
+		current:         client,
+		currentEndpoint: endpoint,
+		next:            1 % len(endpoints),
+	}, nil
+}
+
+// Recv retrieves the next response, transparently reconnecting to the next
+// orderer endpoint on error until it succeeds or the retry budget defined by
+// BackoffConfig.MaxRetries is exhausted.
+func (c *reconnectingClient) Recv() (*orderer.DeliverResponse, error) {
+	for {
+		client, closed := c.activeClient()
+		if closed {
+			return nil, errors.New("blocksprovider: client closed")
+		}
+
+		msg, err := client.Recv()
+		if err == nil {
+			return msg, nil
+		}
+
+		if reconnectErr := c.reconnect(err); reconnectErr != nil {
+			return nil, reconnectErr
+		}
+	}
+}
+
+// Send forwards env to the currently active endpoint.
+func (c *reconnectingClient) Send(env *common.Envelope) error {
+	client, closed := c.activeClient()
+	if closed {
+		return errors.New("blocksprovider: client closed")
+	}
+	return client.Send(env)
+}
+
+// Close tears down the active connection and marks the client closed so
+// that any in-flight Recv/Send and future reconnect attempts stop.
+func (c *reconnectingClient) Close() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.closed {
+		return
+	}
+	c.closed = true
+	if c.current != nil {
+		c.current.Close()
+	}
+}
+
+func (c *reconnectingClient) activeClient() (streamClient, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.current, c.closed
+}
+
+// reconnect fails over to successive endpoints, backing off between
+// attempts, until one connects, the retry budget is exhausted, or the
+// client is closed.
+func (c *reconnectingClient) reconnect(cause error) error {
+	c.mutex.Lock()
+	endpoint, hook, old := c.currentEndpoint, c.onDisconnect, c.current
+	c.mutex.Unlock()
+
+	if hook != nil {
+		hook(endpoint, cause)
+	}
+	if old != nil {
+		old.Close()
+	}
+
+	for attempt := 1; ; attempt++ {
+		c.mutex.Lock()
+		if c.closed {
+			c.mutex.Unlock()
+			return errors.New("blocksprovider: client closed")
+		}
+		if c.backoff.MaxRetries > 0 && attempt > c.backoff.MaxRetries*len(c.endpoints) {
+			c.mutex.Unlock()
+			return ErrOrderersExhausted
+		}
+		endpoint = c.endpoints[c.next]
+		c.next = (c.next + 1) % len(c.endpoints)
+		c.mutex.Unlock()
+
+		time.Sleep(c.backoffDelay(attempt))
+
+		// Seek from height, the same as the initial dial in
+		// NewReconnectingClient: LedgerHeight is the count of committed
+		// blocks, which is also the sequence number of the next block this
+		// peer needs, whether this is the first connection or a failover.
+		height, err := c.ledger.LedgerHeight()
+		if err != nil {
+			logger.Warningf("Failed querying ledger height before reconnecting to %s: %s", endpoint, err)
+			continue
+		}
+
+		client, err := c.dial(endpoint, height)
+		if err != nil {
+			logger.Warningf("Failed reconnecting to orderer %s: %s", endpoint, err)
+			continue
+		}
+
+		c.mutex.Lock()
+		if c.closed {
+			c.mutex.Unlock()
+			client.Close()
+			return errors.New("blocksprovider: client closed")
+		}
+		c.current = client
+		c.currentEndpoint = endpoint
+		c.mutex.Unlock()
+		atomic.AddUint64(&c.reconnects, 1)
+		return nil
+	}
+}
+
+// ReconnectCount returns how many times the client has transparently failed
+// over to a new endpoint since it was created. It implements
+// reconnectNotifier so that blocksProviderImpl can detect a failover and
+// react to it (see checkReconnect).
+func (c *reconnectingClient) ReconnectCount() uint64 {
+	return atomic.LoadUint64(&c.reconnects)
+}
+
+// backoffDelay computes the exponential delay for a given retry attempt
+// (1-indexed), capped and randomized per BackoffConfig.
+func (c *reconnectingClient) backoffDelay(attempt int) time.Duration {
+	shift := attempt - 1
+	if shift > 31 {
+		shift = 31
+	}
+	delay := c.backoff.Base << uint(shift)
+	if c.backoff.Cap > 0 && delay > c.backoff.Cap {
+		delay = c.backoff.Cap
+	}
+	if c.backoff.Jitter > 0 {
+		jitter := float64(delay) * c.backoff.Jitter
+		delay = delay - time.Duration(jitter) + time.Duration(rand.Float64()*2*jitter)
+		if c.backoff.Cap > 0 && delay > c.backoff.Cap {
+			delay = c.backoff.Cap
+		}
+	}
+	return delay
+}
@@ -0,0 +1,269 @@
+/*
+Copyright IBM Corp. 2017 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package blocksprovider
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/orderer"
+)
+
+// fakeLedger reports a fixed height for every reconnect attempt.
+type fakeLedger struct{ height uint64 }
+
+func (l *fakeLedger) LedgerHeight() (uint64, error) { return l.height, nil }
+
+// fakeDialClient is a streamClient whose Recv always errors, used to force a
+// reconnectingClient to fail over.
+type fakeDialClient struct {
+	endpoint string
+	closed   int32
+}
+
+func (c *fakeDialClient) Recv() (*orderer.DeliverResponse, error) {
+	return nil, errors.New("simulated stream error on " + c.endpoint)
+}
+
+func (c *fakeDialClient) Send(*common.Envelope) error { return nil }
+
+func (c *fakeDialClient) Close() { atomic.StoreInt32(&c.closed, 1) }
+
+// noBackoff makes reconnect attempts effectively instantaneous so tests run
+// fast, while still exercising the real retry/backoff machinery.
+var noBackoff = BackoffConfig{Base: time.Microsecond, Cap: time.Millisecond, Jitter: 0}
+
+func TestReconnectingClientFailsOverOnRecvError(t *testing.T) {
+	endpoints := []string{"orderer0", "orderer1"}
+	dialed := make(chan string, len(endpoints)+1)
+
+	dial := func(endpoint string, seq uint64) (streamClient, error) {
+		select {
+		case dialed <- endpoint:
+		default:
+		}
+		return &fakeDialClient{endpoint: endpoint}, nil
+	}
+
+	client, err := NewReconnectingClient(endpoints, dial, &fakeLedger{height: 1}, noBackoff, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	<-dialed // consume the initial dial
+
+	rc := client.(*reconnectingClient)
+	if rc.ReconnectCount() != 0 {
+		t.Fatalf("expected 0 reconnects before any failure, got %d", rc.ReconnectCount())
+	}
+
+	// Recv on the initial (erroring) endpoint triggers a failover to the
+	// next endpoint, which also errors, forcing Recv to keep cycling
+	// through endpoints until the caller gives up waiting.
+	done := make(chan struct{})
+	go func() {
+		client.Recv()
+		close(done)
+	}()
+
+	select {
+	case endpoint := <-dialed:
+		if endpoint != "orderer1" {
+			t.Fatalf("expected failover to orderer1, got %s", endpoint)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for failover dial")
+	}
+
+	if count := rc.ReconnectCount(); count == 0 {
+		t.Fatal("expected ReconnectCount to be incremented after a successful failover")
+	}
+
+	client.Close()
+	<-done
+}
+
+func TestReconnectingClientExhaustsRetryBudget(t *testing.T) {
+	endpoints := []string{"orderer0"}
+
+	// The first dial, made synchronously by NewReconnectingClient, succeeds;
+	// every subsequent dial attempted during reconnect fails, so the retry
+	// budget below is actually exercised.
+	var dialCount int32
+	dial := func(endpoint string, seq uint64) (streamClient, error) {
+		if atomic.AddInt32(&dialCount, 1) == 1 {
+			return &fakeDialClient{endpoint: endpoint}, nil
+		}
+		return nil, errors.New("simulated dial failure")
+	}
+
+	backoff := noBackoff
+	backoff.MaxRetries = 1
+
+	client, err := NewReconnectingClient(endpoints, dial, &fakeLedger{height: 1}, backoff, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.Recv()
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != ErrOrderersExhausted {
+			t.Fatalf("expected ErrOrderersExhausted, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for retry budget to be exhausted")
+	}
+}
+
+func TestReconnectingClientClosesRaceDialedClient(t *testing.T) {
+	endpoints := []string{"orderer0"}
+	dialStarted := make(chan struct{})
+	releaseDial := make(chan struct{})
+	newClients := make(chan *fakeDialClient, 1)
+
+	// The first dial, made synchronously by NewReconnectingClient, returns
+	// immediately; the second dial (triggered by the first Recv error)
+	// blocks until the test signals it, so Close can race it.
+	var dialCount int32
+	dial := func(endpoint string, seq uint64) (streamClient, error) {
+		if atomic.AddInt32(&dialCount, 1) == 1 {
+			return &fakeDialClient{endpoint: endpoint}, nil
+		}
+		close(dialStarted)
+		<-releaseDial
+		client := &fakeDialClient{endpoint: endpoint}
+		newClients <- client
+		return client, nil
+	}
+
+	client, err := NewReconnectingClient(endpoints, dial, &fakeLedger{height: 1}, noBackoff, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	recvDone := make(chan error, 1)
+	go func() {
+		_, err := client.Recv()
+		recvDone <- err
+	}()
+
+	select {
+	case <-dialStarted:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the racing dial to start")
+	}
+
+	// Close fires while the reconnect dial above is still in flight.
+	client.Close()
+	close(releaseDial)
+
+	var dialedClient *fakeDialClient
+	select {
+	case dialedClient = <-newClients:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the racing dial to finish")
+	}
+
+	select {
+	case <-recvDone:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for Recv to return after Close raced a reconnect")
+	}
+
+	if atomic.LoadInt32(&dialedClient.closed) != 1 {
+		t.Fatal("expected the connection dialed during the close race to be closed, not leaked")
+	}
+}
+
+func TestReconnectDialsSameHeightSemanticAsInitialDial(t *testing.T) {
+	endpoints := []string{"orderer0"}
+	var mu sync.Mutex
+	var seqs []uint64
+
+	dial := func(endpoint string, seq uint64) (streamClient, error) {
+		mu.Lock()
+		seqs = append(seqs, seq)
+		mu.Unlock()
+		return &fakeDialClient{endpoint: endpoint}, nil
+	}
+
+	client, err := NewReconnectingClient(endpoints, dial, &fakeLedger{height: 7}, noBackoff, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		client.Recv()
+		close(done)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		mu.Lock()
+		count := len(seqs)
+		mu.Unlock()
+		if count >= 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for a reconnect dial after the initial dial")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	client.Close()
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	for i, seq := range seqs {
+		if seq != 7 {
+			t.Fatalf("expected every dial (including reconnects) to seek at ledger height 7, dial #%d sought %d: %v", i, seq, seqs)
+		}
+	}
+}
+
+func TestReconnectingClientCloseStopsReconnecting(t *testing.T) {
+	endpoints := []string{"orderer0", "orderer1"}
+	dial := func(endpoint string, seq uint64) (streamClient, error) {
+		return &fakeDialClient{endpoint: endpoint}, nil
+	}
+
+	client, err := NewReconnectingClient(endpoints, dial, &fakeLedger{height: 1}, noBackoff, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	client.Close()
+
+	if _, err := client.Recv(); err == nil {
+		t.Fatal("expected Recv to error once the client is closed")
+	}
+	if err := client.Send(nil); err == nil {
+		t.Fatal("expected Send to error once the client is closed")
+	}
+}